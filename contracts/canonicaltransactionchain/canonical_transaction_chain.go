@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package canonicaltransactionchain is a thin, hand-maintained stand-in for
+// the abigen-generated bindings of the OVM CanonicalTransactionChain
+// contract. It only exposes the pieces that rollup.SyncService needs in
+// order to filter and decode the events it cares about.
+package canonicaltransactionchain
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OVMCanonicalTransactionChainFilterer wraps a bind.ContractFilterer bound to
+// the CTC contract address so that raw logs can be unpacked into the typed
+// event structs below.
+type OVMCanonicalTransactionChainFilterer struct {
+	contract *bind.BoundContract
+}
+
+// NewOVMCanonicalTransactionChainFilterer binds a new filterer instance to
+// the CTC contract at address, using filterer for log queries.
+func NewOVMCanonicalTransactionChainFilterer(address common.Address, filterer bind.ContractFilterer) (*OVMCanonicalTransactionChainFilterer, error) {
+	contract := bind.NewBoundContract(address, abi.ABI{}, nil, nil, filterer)
+	return &OVMCanonicalTransactionChainFilterer{contract: contract}, nil
+}
+
+// TransactionEnqueued is the decoded form of the TransactionEnqueued event,
+// emitted by the CTC whenever an L1 call to `enqueue` is made.
+type TransactionEnqueued struct {
+	L1TxOrigin common.Address
+	Target     common.Address
+	GasLimit   *big.Int
+	Data       []byte
+	QueueIndex *big.Int
+	Timestamp  *big.Int
+	Raw        types.Log
+}
+
+// QueueBatchAppended is the decoded form of the QueueBatchAppended event,
+// emitted by the CTC whenever a batch of previously enqueued transactions is
+// appended to the canonical chain.
+type QueueBatchAppended struct {
+	StartingQueueIndex *big.Int
+	NumQueueElements   *big.Int
+	TotalElements      *big.Int
+	Raw                types.Log
+}
+
+// SequencerBatchAppended is the decoded form of the SequencerBatchAppended
+// event, emitted by the CTC whenever the sequencer appends a batch of
+// transactions directly (as opposed to via the enqueue/append-queue path).
+type SequencerBatchAppended struct {
+	StartingQueueIndex *big.Int
+	NumQueueElements   *big.Int
+	TotalElements      *big.Int
+	Raw                types.Log
+}
+
+var (
+	transactionEnqueuedArgs = abi.Arguments{
+		{Name: "l1TxOrigin", Type: mustType("address")},
+		{Name: "target", Type: mustType("address")},
+		{Name: "gasLimit", Type: mustType("uint256")},
+		{Name: "data", Type: mustType("bytes")},
+		{Name: "queueIndex", Type: mustType("uint256")},
+		{Name: "timestamp", Type: mustType("uint256")},
+	}
+	queueBatchAppendedArgs = abi.Arguments{
+		{Name: "startingQueueIndex", Type: mustType("uint256")},
+		{Name: "numQueueElements", Type: mustType("uint256")},
+		{Name: "totalElements", Type: mustType("uint256")},
+	}
+)
+
+func mustType(t string) abi.Type {
+	typ, err := abi.NewType(t, t, nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
+
+// ParseTransactionEnqueued unpacks a raw log into a TransactionEnqueued event.
+func (f *OVMCanonicalTransactionChainFilterer) ParseTransactionEnqueued(log types.Log) (*TransactionEnqueued, error) {
+	values, err := transactionEnqueuedArgs.Unpack(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	event := new(TransactionEnqueued)
+	if err := transactionEnqueuedArgs.Copy(event, values); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseQueueBatchAppended unpacks a raw log into a QueueBatchAppended event.
+func (f *OVMCanonicalTransactionChainFilterer) ParseQueueBatchAppended(log types.Log) (*QueueBatchAppended, error) {
+	values, err := queueBatchAppendedArgs.Unpack(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	event := new(QueueBatchAppended)
+	if err := queueBatchAppendedArgs.Copy(event, values); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseSequencerBatchAppended unpacks a raw log into a SequencerBatchAppended
+// event. The event shares its ABI shape with QueueBatchAppended.
+func (f *OVMCanonicalTransactionChainFilterer) ParseSequencerBatchAppended(log types.Log) (*SequencerBatchAppended, error) {
+	values, err := queueBatchAppendedArgs.Unpack(log.Data)
+	if err != nil {
+		return nil, err
+	}
+	event := new(SequencerBatchAppended)
+	if err := queueBatchAppendedArgs.Copy(event, values); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// MessageStatus describes where an L1->L2 message is in its lifecycle, from
+// being enqueued on L1 through to landing in an L2 block.
+type MessageStatus string
+
+const (
+	MessageStatusUnknown  MessageStatus = "unknown"
+	MessageStatusEnqueued MessageStatus = "enqueued"
+	MessageStatusBatched  MessageStatus = "batched"
+	MessageStatusExecuted MessageStatus = "executed"
+)
+
+// SyncStatus is the result of the rollup_syncStatus RPC method.
+type SyncStatus struct {
+	CurrentL1Block          hexutil.Uint64 `json:"currentL1Block"`
+	HighestL1Block          hexutil.Uint64 `json:"highestL1Block"`
+	LastProcessedQueueIndex hexutil.Uint64 `json:"lastProcessedQueueIndex"`
+	LastAppendedBatchIndex  hexutil.Uint64 `json:"lastAppendedBatchIndex"`
+	TxCacheSize             hexutil.Uint64 `json:"txCacheSize"`
+}
+
+// EnqueuedTransaction is the result of the rollup_getEnqueuedTransaction RPC
+// method.
+type EnqueuedTransaction struct {
+	QueueIndex    hexutil.Uint64 `json:"queueIndex"`
+	L1TxOrigin    common.Address `json:"origin"`
+	Target        common.Address `json:"target"`
+	GasLimit      hexutil.Uint64 `json:"gasLimit"`
+	Timestamp     hexutil.Uint64 `json:"timestamp"`
+	Data          hexutil.Bytes  `json:"data"`
+	L1BlockNumber hexutil.Uint64 `json:"l1BlockNumber"`
+}
+
+// PublicRollupAPI exposes read-only L1<->L2 indexing state over the `rollup`
+// JSON-RPC namespace.
+type PublicRollupAPI struct {
+	s *SyncService
+}
+
+// NewPublicRollupAPI creates a new PublicRollupAPI backed by s.
+func NewPublicRollupAPI(s *SyncService) *PublicRollupAPI {
+	return &PublicRollupAPI{s: s}
+}
+
+// APIs returns the RPC descriptor for the `rollup` namespace, for the node
+// to register alongside starting the service's Loop.
+func (s *SyncService) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "rollup",
+			Version:   "1.0",
+			Service:   NewPublicRollupAPI(s),
+			Public:    true,
+		},
+	}
+}
+
+// SyncStatus returns the current state of the L1 indexer.
+func (api *PublicRollupAPI) SyncStatus() SyncStatus {
+	var cacheSize uint64
+	api.s.txCache.Range(func(uint64, *RollupTransaction) bool {
+		cacheSize++
+		return true
+	})
+
+	return SyncStatus{
+		CurrentL1Block:          hexutil.Uint64(api.s.Eth1Data.BlockHeight),
+		HighestL1Block:          hexutil.Uint64(api.s.highestL1Block()),
+		LastProcessedQueueIndex: hexutil.Uint64(api.s.nextQueueIndex),
+		LastAppendedBatchIndex:  hexutil.Uint64(api.s.batchCount),
+		TxCacheSize:             hexutil.Uint64(cacheSize),
+	}
+}
+
+// GetEnqueuedTransaction returns the cached rollup transaction at queueIndex,
+// or an error if it is not (or is no longer) in the cache.
+func (api *PublicRollupAPI) GetEnqueuedTransaction(queueIndex hexutil.Uint64) (*EnqueuedTransaction, error) {
+	rtx, ok := api.s.txCache.Load(uint64(queueIndex))
+	if !ok {
+		return nil, fmt.Errorf("no enqueued transaction at queue index %d", uint64(queueIndex))
+	}
+	return &EnqueuedTransaction{
+		QueueIndex:    queueIndex,
+		L1TxOrigin:    *rtx.tx.L1MessageSender(),
+		Target:        *rtx.tx.To(),
+		GasLimit:      hexutil.Uint64(rtx.tx.Gas()),
+		Timestamp:     hexutil.Uint64(rtx.timestamp.Unix()),
+		Data:          rtx.tx.Data(),
+		L1BlockNumber: hexutil.Uint64(rtx.blockHeight),
+	}, nil
+}
+
+// GetL1ToL2MessageStatus reports where the message at queueIndex is in its
+// lifecycle. nextQueueIndex, not tx cache membership, is what actually
+// distinguishes "batched" from "enqueued": a cached entry is kept around
+// after it is consumed (see applyQueueBatchAppended) so that a resumed
+// backfill can still recognize it, so cache membership alone cannot tell the
+// two apart. "executed" is only ever returned when the sync service is
+// running in verifier mode, since only the verifier confirms a message was
+// actually replayed against the local chain rather than merely handed to
+// the mempool; outside verifier mode a batched message never advances past
+// "batched".
+func (api *PublicRollupAPI) GetL1ToL2MessageStatus(queueIndex hexutil.Uint64) MessageStatus {
+	idx := uint64(queueIndex)
+	if idx < api.s.nextQueueIndex {
+		if _, ok := api.s.executedQueueIndices.Load(idx); ok {
+			return MessageStatusExecuted
+		}
+		return MessageStatusBatched
+	}
+	if _, ok := api.s.txCache.Load(idx); ok {
+		return MessageStatusEnqueued
+	}
+	return MessageStatusUnknown
+}
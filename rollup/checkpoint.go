@@ -0,0 +1,123 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// defaultLogBackfillWindowSize bounds how many L1 blocks are requested in a
+// single FilterLogs call when Config.LogBackfillWindowSize is unset.
+const defaultLogBackfillWindowSize = 2000
+
+// logCheckpointKey is the database key under which the sync service's
+// progress through the CTC log stream is persisted. Unlike the coarser
+// Eth1Data checkpoint, which only advances once an entire header's worth of
+// logs has been applied, this one is updated after every individual log, so
+// that a crash mid-backfill resumes without resubmitting transactions that
+// were already applied.
+var logCheckpointKey = []byte("rollup-log-checkpoint")
+
+// logCheckpoint records the sync service's progress through the CTC log
+// stream, at a finer grain than Eth1Data. The tx cache it depends on to
+// assemble batches is not itself persisted, so a restart always re-filters
+// and re-applies the window a crash happened in; these fields are what let
+// that replay recognize work it already did and avoid repeating it.
+type logCheckpoint struct {
+	// LastScannedL1Block is the upper bound of the most recent window in
+	// which at least one log was applied, recorded for operator visibility
+	// into backfill progress. A window with no logs does not update it.
+	LastScannedL1Block uint64
+	// LastProcessedQueueIndex mirrors SyncService.nextQueueIndex: the queue
+	// index up to which enqueued transactions have already been submitted,
+	// so a replayed QueueBatchAppended/SequencerBatchAppended element that
+	// references it is skipped rather than resubmitted.
+	LastProcessedQueueIndex uint64
+	// LastProcessedBatchIndex mirrors SyncService.batchCount, restored so
+	// the `rollup` RPC namespace reports a consistent count across restarts.
+	LastProcessedBatchIndex uint64
+	// LastProcessedLogIndex mirrors SyncService.lastBatchTotalElements: the
+	// CTC's own cumulative TotalElements count as of the last fully applied
+	// batch log. Since it only ever grows, comparing an incoming batch's
+	// TotalElements against it tells a replay whether the whole batch -
+	// including any sequencer-signed elements, which carry no queue index
+	// of their own - was already applied before the crash.
+	LastProcessedLogIndex uint64
+}
+
+// GetLogCheckpoint reads the last persisted log checkpoint from db. It
+// returns the zero value if none has been written yet.
+func GetLogCheckpoint(db ethdb.Database) logCheckpoint {
+	raw, err := db.Get(logCheckpointKey)
+	if err != nil || len(raw) != 32 {
+		return logCheckpoint{}
+	}
+	return logCheckpoint{
+		LastScannedL1Block:      binary.BigEndian.Uint64(raw[0:8]),
+		LastProcessedQueueIndex: binary.BigEndian.Uint64(raw[8:16]),
+		LastProcessedBatchIndex: binary.BigEndian.Uint64(raw[16:24]),
+		LastProcessedLogIndex:   binary.BigEndian.Uint64(raw[24:32]),
+	}
+}
+
+// writeLogCheckpoint persists checkpoint as the sync service's current
+// log-scanning progress.
+func writeLogCheckpoint(db ethdb.Database, checkpoint logCheckpoint) error {
+	raw := make([]byte, 32)
+	binary.BigEndian.PutUint64(raw[0:8], checkpoint.LastScannedL1Block)
+	binary.BigEndian.PutUint64(raw[8:16], checkpoint.LastProcessedQueueIndex)
+	binary.BigEndian.PutUint64(raw[16:24], checkpoint.LastProcessedBatchIndex)
+	binary.BigEndian.PutUint64(raw[24:32], checkpoint.LastProcessedLogIndex)
+	return db.Put(logCheckpointKey, raw)
+}
+
+// applyLogWindow fetches and applies every CTC log between from and to,
+// inclusive, persisting the log checkpoint after each one so that a crash
+// partway through leaves behind an accurate record of what was applied.
+func (s *SyncService) applyLogWindow(from, to uint64) error {
+	query := ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{s.ctcAddress},
+	}
+	logs, err := s.logClient.FilterLogs(s.ctx, query)
+	if err != nil {
+		return fmt.Errorf("cannot filter logs: %w", err)
+	}
+
+	for _, l := range logs {
+		if err := s.applyLog(l); err != nil {
+			return err
+		}
+		s.checkpoint = logCheckpoint{
+			LastScannedL1Block:      to,
+			LastProcessedQueueIndex: s.nextQueueIndex,
+			LastProcessedBatchIndex: s.batchCount,
+			LastProcessedLogIndex:   s.lastBatchTotalElements,
+		}
+		if err := writeLogCheckpoint(s.db, s.checkpoint); err != nil {
+			return fmt.Errorf("cannot persist log checkpoint: %w", err)
+		}
+	}
+	return nil
+}
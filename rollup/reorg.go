@@ -0,0 +1,236 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// reorgAncestorSearchLimit is the number of recent L1 block hashes the sync
+// service's blockHashRing keeps, and so bounds how far back findCommonAncestor
+// can compare hashes. A reorg deeper than this is not an error:
+// findCommonAncestor stops at the first height with no recorded hash and
+// treats that height as the ancestor.
+const reorgAncestorSearchLimit = 10000
+
+// reorgedMeter counts every L1 reorg the sync service has had to roll back.
+var reorgedMeter = metrics.NewRegisteredMeter("rollup/sync/reorged", nil)
+
+// blockHashHistoryPrefix namespaces the per-block-number keys a blockHashRing
+// persists its entries under, in the same database used by
+// GetLastProcessedEth1Data.
+var blockHashHistoryPrefix = []byte("rollup-l1-block-hash-")
+
+// blockHashHistoryOrderKey is the database key under which a blockHashRing
+// persists the ordered list of block numbers it currently holds, oldest
+// first, so that NewSyncService can restore both the entries and their
+// eviction order across a restart.
+var blockHashHistoryOrderKey = []byte("rollup-l1-block-hash-order")
+
+// blockHashHistoryKey returns the database key an individual block hash is
+// persisted under.
+func blockHashHistoryKey(number uint64) []byte {
+	key := make([]byte, len(blockHashHistoryPrefix)+8)
+	copy(key, blockHashHistoryPrefix)
+	binary.BigEndian.PutUint64(key[len(blockHashHistoryPrefix):], number)
+	return key
+}
+
+// blockHashRing is a bounded, persisted record of the L1 block hashes the
+// sync service has already applied, indexed by block number. It backs
+// findCommonAncestor so that a reorg can be resolved against recently seen
+// history without refetching it from L1, and it survives a restart so that a
+// reorg that happens while the node is down is not silently under-resolved.
+type blockHashRing struct {
+	db     ethdb.Database
+	limit  int
+	hashes map[uint64]common.Hash
+	order  []uint64
+}
+
+// newBlockHashRing creates a blockHashRing backed by db, restoring any
+// entries persisted by a previous run.
+func newBlockHashRing(db ethdb.Database, limit int) *blockHashRing {
+	r := &blockHashRing{
+		db:     db,
+		limit:  limit,
+		hashes: make(map[uint64]common.Hash),
+	}
+	r.restore()
+	return r
+}
+
+// restore loads the persisted order manifest and the block hash recorded at
+// each of its entries. A missing or malformed manifest is treated the same as
+// an empty ring, and an entry whose individual hash is missing is dropped
+// while the rest are kept; the worst case is
+// findCommonAncestor falling back to treating the oldest available height as
+// the ancestor, exactly as it does for a ring that was never persisted.
+func (r *blockHashRing) restore() {
+	raw, err := r.db.Get(blockHashHistoryOrderKey)
+	if err != nil || len(raw)%8 != 0 {
+		return
+	}
+	for i := 0; i+8 <= len(raw); i += 8 {
+		number := binary.BigEndian.Uint64(raw[i : i+8])
+		hashRaw, err := r.db.Get(blockHashHistoryKey(number))
+		if err != nil || len(hashRaw) != common.HashLength {
+			continue
+		}
+		r.order = append(r.order, number)
+		r.hashes[number] = common.BytesToHash(hashRaw)
+	}
+}
+
+// Add records hash as the canonical hash at number, persists it, and evicts
+// the oldest entry once the ring exceeds its limit.
+func (r *blockHashRing) Add(number uint64, hash common.Hash) error {
+	if _, ok := r.hashes[number]; !ok {
+		r.order = append(r.order, number)
+	}
+	r.hashes[number] = hash
+	for len(r.order) > r.limit {
+		stale := r.order[0]
+		r.order = r.order[1:]
+		delete(r.hashes, stale)
+		if err := r.db.Delete(blockHashHistoryKey(stale)); err != nil {
+			return fmt.Errorf("cannot evict stale L1 block hash %d: %w", stale, err)
+		}
+	}
+	if err := r.db.Put(blockHashHistoryKey(number), hash.Bytes()); err != nil {
+		return fmt.Errorf("cannot persist L1 block hash %d: %w", number, err)
+	}
+	return r.writeOrder()
+}
+
+// Get returns the recorded hash at number, if any.
+func (r *blockHashRing) Get(number uint64) (common.Hash, bool) {
+	hash, ok := r.hashes[number]
+	return hash, ok
+}
+
+// TrimAbove discards every recorded entry above ancestor, from both memory
+// and the database.
+func (r *blockHashRing) TrimAbove(ancestor uint64) error {
+	kept := r.order[:0]
+	for _, number := range r.order {
+		if number > ancestor {
+			delete(r.hashes, number)
+			if err := r.db.Delete(blockHashHistoryKey(number)); err != nil {
+				return fmt.Errorf("cannot evict orphaned L1 block hash %d: %w", number, err)
+			}
+			continue
+		}
+		kept = append(kept, number)
+	}
+	r.order = kept
+	return r.writeOrder()
+}
+
+// writeOrder persists the current eviction order as a flat list of 8-byte
+// block numbers, oldest first.
+func (r *blockHashRing) writeOrder() error {
+	raw := make([]byte, len(r.order)*8)
+	for i, number := range r.order {
+		binary.BigEndian.PutUint64(raw[i*8:], number)
+	}
+	return r.db.Put(blockHashHistoryOrderKey, raw)
+}
+
+// findCommonAncestor walks backwards from header, comparing the canonical L1
+// hash at each height (fetched via HeaderByNumber) against the hash recorded
+// in the local ring buffer, until it finds a height where they agree. That
+// height is the last common ancestor between the old and new L1 branches.
+//
+// Callers must only invoke this for a header that genuinely competes with
+// the stored head, i.e. one at or below Eth1Data.BlockHeight+1 with a
+// different ParentHash. A header further ahead is an ordinary forward gap,
+// not a reorg, and walking back from it would hit the ring's empty entries
+// long before any real divergence and misreport a height as the ancestor
+// that was simply never scanned.
+func (s *SyncService) findCommonAncestor(header *types.Header) (uint64, error) {
+	deployHeight := s.ctcDeployHeight.Uint64()
+	for n := header.Number.Uint64() - 1; n > deployHeight; n-- {
+		localHash, ok := s.l1BlockHashes.Get(n)
+		if !ok {
+			// Nothing recorded locally at this height -- the reorg reaches
+			// further back than the persisted ring covers, so treat this
+			// height as the ancestor since there is nothing further back to
+			// compare against.
+			return n, nil
+		}
+		remote, err := s.ethclient.HeaderByNumber(s.ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return 0, fmt.Errorf("cannot fetch L1 header %d: %w", n, err)
+		}
+		if remote.Hash() == localHash {
+			return n, nil
+		}
+	}
+	return deployHeight, nil
+}
+
+// rollbackTo trims the persisted Eth1Data checkpoint back to ancestor and
+// evicts every cached transaction that was observed on a now-orphaned L1
+// block, so that the subsequent re-scan from ancestor+1 starts from a clean
+// slate.
+func (s *SyncService) rollbackTo(ancestor uint64) error {
+	reorgedMeter.Mark(1)
+
+	s.txCache.Range(func(index uint64, rtx *RollupTransaction) bool {
+		if rtx.blockHeight > ancestor {
+			s.txCache.Delete(index)
+		}
+		return true
+	})
+
+	if err := s.l1BlockHashes.TrimAbove(ancestor); err != nil {
+		return fmt.Errorf("cannot evict orphaned L1 block hashes: %w", err)
+	}
+
+	// The re-scan from ancestor+1 will re-derive the new canonical branch's
+	// own queue indices and batch events from scratch, which may legitimately
+	// differ from the orphaned branch's. nextQueueIndex, batchCount and
+	// lastBatchTotalElements exist purely to recognize a *replay* of work
+	// already done; since nothing past ancestor has been done on the new
+	// branch yet, they must be rolled back too, or the crash-recovery gates
+	// in applyQueueBatchAppended/applySequencerBatchAppended could mistake a
+	// legitimate new batch for one already applied and silently drop it.
+	s.nextQueueIndex = 0
+	s.batchCount = 0
+	s.lastBatchTotalElements = 0
+	s.checkpoint = logCheckpoint{}
+	if err := writeLogCheckpoint(s.db, s.checkpoint); err != nil {
+		return fmt.Errorf("cannot reset log checkpoint: %w", err)
+	}
+
+	header, err := s.ethclient.HeaderByNumber(s.ctx, new(big.Int).SetUint64(ancestor))
+	if err != nil {
+		return err
+	}
+
+	eth1data := Eth1Data{BlockHeight: ancestor, BlockHash: header.Hash()}
+	s.Eth1Data = eth1data
+	return s.writeLastProcessedEth1Data(eth1data)
+}
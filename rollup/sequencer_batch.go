@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// sequencerBatchElementType distinguishes a sequencer-signed transaction
+// from a reference into the already-enqueued (L1->L2) queue inside a decoded
+// appendSequencerBatch payload.
+type sequencerBatchElementType uint8
+
+const (
+	sequencerBatchElementQueued    sequencerBatchElementType = 0
+	sequencerBatchElementSequencer sequencerBatchElementType = 1
+)
+
+// sequencerBatchElement is a single decoded entry from an
+// appendSequencerBatch calldata payload.
+type sequencerBatchElement struct {
+	kind sequencerBatchElementType
+	tx   *types.Transaction // only set when kind == sequencerBatchElementSequencer
+}
+
+// decodeAppendSequencerBatchCalldata decodes the calldata passed to the
+// CTC's appendSequencerBatch method (with the 4-byte selector already
+// stripped), following its wire format:
+//
+//	uint24 numElements
+//	numElements * {
+//	  uint8  kind            (0 = queued reference, 1 = sequencer tx)
+//	  // the following fields are only present when kind == sequencer tx
+//	  uint64 timestamp       (unused by the decoder; not carried by the L2 tx)
+//	  uint64 blockNumber     (must match the payload's own L1BlockNumber)
+//	  uint8  txType          (unused by the decoder; the payload is self-describing)
+//	  uint32 payloadLength
+//	  []byte payload         (RLP-encoded signed L2 transaction)
+//	}
+func decodeAppendSequencerBatchCalldata(data []byte) ([]sequencerBatchElement, error) {
+	if len(data) < 3 {
+		return nil, fmt.Errorf("sequencer batch calldata too short")
+	}
+	numElements := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+	offset := 3
+
+	// Every element consumes at least one byte (its kind), so a numElements
+	// beyond what data can possibly hold is malformed; reject it before
+	// preallocating a slice sized off of attacker/sequencer-controlled input.
+	if remaining := uint32(len(data) - offset); numElements > remaining {
+		return nil, fmt.Errorf("sequencer batch calldata declares %d elements but only has room for %d", numElements, remaining)
+	}
+
+	elements := make([]sequencerBatchElement, 0, numElements)
+	for i := uint32(0); i < numElements; i++ {
+		if offset >= len(data) {
+			return nil, fmt.Errorf("sequencer batch calldata truncated at element %d", i)
+		}
+		kind := sequencerBatchElementType(data[offset])
+		offset++
+
+		if kind == sequencerBatchElementQueued {
+			elements = append(elements, sequencerBatchElement{kind: kind})
+			continue
+		}
+
+		const headerLen = 21 // timestamp(8) + blockNumber(8) + txType(1) + payloadLength(4)
+		if offset+headerLen > len(data) {
+			return nil, fmt.Errorf("sequencer batch calldata truncated at element %d header", i)
+		}
+		blockNumber := binary.BigEndian.Uint64(data[offset+8 : offset+16])
+		payloadLength := binary.BigEndian.Uint32(data[offset+17 : offset+21])
+		offset += headerLen
+
+		if offset+int(payloadLength) > len(data) {
+			return nil, fmt.Errorf("sequencer batch calldata truncated at element %d payload", i)
+		}
+		payload := data[offset : offset+int(payloadLength)]
+		offset += int(payloadLength)
+
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(payload, tx); err != nil {
+			return nil, fmt.Errorf("cannot decode sequencer tx at element %d: %w", i, err)
+		}
+		if l1BlockNumber := tx.L1BlockNumber(); l1BlockNumber != nil && l1BlockNumber.Uint64() != blockNumber {
+			return nil, fmt.Errorf("sequencer tx at element %d declares L1 block %d but its batch header says %d", i, l1BlockNumber.Uint64(), blockNumber)
+		}
+
+		elements = append(elements, sequencerBatchElement{kind: kind, tx: tx})
+	}
+	return elements, nil
+}
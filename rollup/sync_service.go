@@ -0,0 +1,618 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rollup implements the L1 indexing service that feeds the
+// CanonicalTransactionChain's events into the local mempool, allowing an L2
+// node to stay in sync with the rollup's source of truth on L1.
+package rollup
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ctc "github.com/ethereum/go-ethereum/contracts/canonicaltransactionchain"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// transactionEnqueuedEventSignature is keccak256("TransactionEnqueued(address,address,uint256,bytes,uint256,uint256)").
+	transactionEnqueuedEventSignature = crypto.Keccak256([]byte("TransactionEnqueued(address,address,uint256,bytes,uint256,uint256)"))
+	// queueBatchAppendedEventSignature is keccak256("QueueBatchAppended(uint256,uint256,uint256)").
+	queueBatchAppendedEventSignature = crypto.Keccak256([]byte("QueueBatchAppended(uint256,uint256,uint256)"))
+	// sequencerBatchAppendedEventSignature is keccak256("SequencerBatchAppended(uint256,uint256,uint256)").
+	sequencerBatchAppendedEventSignature = crypto.Keccak256([]byte("SequencerBatchAppended(uint256,uint256,uint256)"))
+
+	// lastProcessedEth1DataKey is the database key under which the most
+	// recently processed L1 (blockNumber, blockHash) pair is persisted.
+	lastProcessedEth1DataKey = []byte("rollup-last-processed-eth1-data")
+)
+
+// Config holds the parameters needed to start a SyncService.
+type Config struct {
+	// CanonicalTransactionChainDeployHeight is the L1 block height at which
+	// the CTC contract was deployed. Historical scanning never needs to go
+	// below this height.
+	CanonicalTransactionChainDeployHeight *big.Int
+	// CanonicalTransactionChainAddress is the address of the CTC contract on L1.
+	CanonicalTransactionChainAddress common.Address
+	// TxIngestionSignerKey signs the transactions that are built out of
+	// decoded L1 events before they are submitted to the local mempool.
+	TxIngestionSignerKey *ecdsa.PrivateKey
+	// IsVerifier puts the sync service into verifier mode: instead of
+	// submitting decoded transactions to the local mempool, it replays them
+	// directly against the local chain and checks that the result matches
+	// what is already stored there.
+	IsVerifier bool
+	// TrustedSequencerURL, when set, is reserved for verifying against a
+	// remote sequencer's blocks when no local block is available yet. It is
+	// not consulted today.
+	TrustedSequencerURL string
+	// LogBackfillWindowSize bounds how many L1 blocks are requested in a
+	// single FilterLogs call while catching up. It defaults to 2000 blocks
+	// if unset, keeping historical backfills under the log-range limits
+	// most L1 providers impose.
+	LogBackfillWindowSize uint64
+}
+
+// EthereumClient is the subset of ethclient.Client that the sync service
+// needs. It is an interface so that tests can supply a mock.
+type EthereumClient interface {
+	ChainID(context.Context) (*big.Int, error)
+	NetworkID(context.Context) (*big.Int, error)
+	SyncProgress(context.Context) (*ethereum.SyncProgress, error)
+	HeaderByNumber(context.Context, *big.Int) (*types.Header, error)
+	TransactionByHash(context.Context, common.Hash) (*types.Transaction, bool, error)
+}
+
+// Eth1Data is the L1 checkpoint the sync service has most recently applied.
+type Eth1Data struct {
+	BlockHeight uint64
+	BlockHash   common.Hash
+}
+
+// RollupTransaction is a transaction that was enqueued on L1 via the CTC's
+// `enqueue` method and is waiting to be appended into a batch.
+type RollupTransaction struct {
+	tx          *types.Transaction
+	timestamp   time.Time
+	blockHeight uint64
+	blockHash   common.Hash
+	queueIndex  uint64
+}
+
+// transactionCache is a small typed wrapper around sync.Map so that callers
+// don't need to perform type assertions on every access.
+type transactionCache struct {
+	inner sync.Map
+}
+
+func (c *transactionCache) Store(index uint64, rtx *RollupTransaction) {
+	c.inner.Store(index, rtx)
+}
+
+func (c *transactionCache) Load(index uint64) (*RollupTransaction, bool) {
+	value, ok := c.inner.Load(index)
+	if !ok {
+		return nil, false
+	}
+	return value.(*RollupTransaction), true
+}
+
+func (c *transactionCache) Delete(index uint64) {
+	c.inner.Delete(index)
+}
+
+// Range iterates over every cached transaction, calling fn for each. Range
+// stops early if fn returns false.
+func (c *transactionCache) Range(fn func(index uint64, rtx *RollupTransaction) bool) {
+	c.inner.Range(func(k, v interface{}) bool {
+		return fn(k.(uint64), v.(*RollupTransaction))
+	})
+}
+
+// key is the god key used to sign transactions built out of decoded L1
+// events before they are submitted to the local mempool.
+var key *ecdsa.PrivateKey
+
+// SyncService indexes the CanonicalTransactionChain contract on L1 and
+// replays its events into the local transaction pool so that the L2 chain
+// can be built on top of them.
+type SyncService struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	txpool *core.TxPool
+	bc     *core.BlockChain
+	db     ethdb.Database
+
+	address common.Address
+
+	ctcAddress      common.Address
+	ctcDeployHeight *big.Int
+
+	// verifier puts the service into verifier mode; see Config.IsVerifier.
+	verifier bool
+
+	ethclient   EthereumClient
+	logClient   bind.ContractFilterer
+	ctcFilterer *ctc.OVMCanonicalTransactionChainFilterer
+
+	heads          chan *types.Header
+	doneProcessing chan uint64
+	// errCh carries the error that caused a header to be dropped, most
+	// notably a *RollupDivergence from the verifier. It is buffered so that
+	// Loop never blocks delivering to it.
+	errCh chan error
+
+	Eth1Data Eth1Data
+	txCache  transactionCache
+
+	// nextQueueIndex is the queue index that will be consumed the next time
+	// a batch takes an entry from txCache, i.e. one past the last queue
+	// index that has actually been processed.
+	nextQueueIndex uint64
+	// batchCount is the number of QueueBatchAppended/SequencerBatchAppended
+	// batches applied so far.
+	batchCount uint64
+	// lastBatchTotalElements is the TotalElements value of the last fully
+	// applied QueueBatchAppended/SequencerBatchAppended log. It gates
+	// against resubmitting a batch that a resumed backfill re-filters
+	// after a crash, since TotalElements only ever grows.
+	lastBatchTotalElements uint64
+
+	// executedQueueIndices records the queue indices whose L1->L2 message
+	// the verifier has confirmed was actually replayed against the local
+	// chain, rather than merely handed to the mempool. It is only ever
+	// populated in verifier mode; see GetL1ToL2MessageStatus.
+	executedQueueIndices sync.Map
+
+	// nextVerifyHeight is the L2 block height verifyTransaction will replay
+	// its next transaction against. The OVM model packs exactly one
+	// transaction per L2 block, so it advances by one after each
+	// transaction that verifies cleanly. It is only used in verifier mode,
+	// and needs no persistence of its own across a restart: it is seeded
+	// from the chain's own current height, which already reflects every
+	// block verified before the restart.
+	nextVerifyHeight uint64
+
+	// l1BlockHashes is a bounded ring of recently processed (blockNumber,
+	// blockHash) pairs, used to resolve reorgs without re-deriving the
+	// common ancestor from scratch. It is persisted in db so that a reorg
+	// which happens while the node is down is still resolved correctly on
+	// restart.
+	l1BlockHashes *blockHashRing
+
+	// logWindowSize bounds how many L1 blocks applyLogsInRange requests in a
+	// single FilterLogs call; see Config.LogBackfillWindowSize.
+	logWindowSize uint64
+	// checkpoint is the most recently persisted log-scanning progress. See
+	// logCheckpoint.
+	checkpoint logCheckpoint
+}
+
+// NewSyncService creates a SyncService which is ready to have its Loop run,
+// but does not start any background processing itself.
+func NewSyncService(ctx context.Context, cfg Config, txpool *core.TxPool, bc *core.BlockChain, db ethdb.Database) (*SyncService, error) {
+	if cfg.TxIngestionSignerKey == nil {
+		return nil, fmt.Errorf("must supply a TxIngestionSignerKey")
+	}
+	key = cfg.TxIngestionSignerKey
+
+	ctx, cancel := context.WithCancel(ctx)
+	address := crypto.PubkeyToAddress(cfg.TxIngestionSignerKey.PublicKey)
+
+	logWindowSize := cfg.LogBackfillWindowSize
+	if logWindowSize == 0 {
+		logWindowSize = defaultLogBackfillWindowSize
+	}
+
+	service := &SyncService{
+		ctx:              ctx,
+		cancel:           cancel,
+		txpool:           txpool,
+		bc:               bc,
+		db:               db,
+		address:          address,
+		ctcAddress:       cfg.CanonicalTransactionChainAddress,
+		ctcDeployHeight:  cfg.CanonicalTransactionChainDeployHeight,
+		verifier:         cfg.IsVerifier,
+		heads:            make(chan *types.Header),
+		doneProcessing:   make(chan uint64),
+		errCh:            make(chan error, 1),
+		nextVerifyHeight: bc.CurrentBlock().NumberU64() + 1,
+		l1BlockHashes:    newBlockHashRing(db, reorgAncestorSearchLimit),
+		logWindowSize:    logWindowSize,
+	}
+
+	service.Eth1Data = service.GetLastProcessedEth1Data()
+	service.checkpoint = GetLogCheckpoint(db)
+	service.nextQueueIndex = service.checkpoint.LastProcessedQueueIndex
+	service.batchCount = service.checkpoint.LastProcessedBatchIndex
+	service.lastBatchTotalElements = service.checkpoint.LastProcessedLogIndex
+
+	return service, nil
+}
+
+// Loop is the main loop of the sync service. It consumes new L1 headers from
+// the heads channel, detects and resolves reorgs, scans for new CTC logs and
+// applies them, and persists the resulting checkpoint.
+func (s *SyncService) Loop() {
+	for {
+		select {
+		case header := <-s.heads:
+			if err := s.handleHeader(header); err != nil {
+				log.Error("Unable to handle header", "number", header.Number, "err", err)
+				select {
+				case s.errCh <- err:
+				default:
+				}
+				continue
+			}
+			s.doneProcessing <- header.Number.Uint64()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// Err returns a channel on which Loop delivers the error that caused it to
+// drop a header, most notably a *RollupDivergence surfaced by the verifier.
+func (s *SyncService) Err() <-chan error {
+	return s.errCh
+}
+
+// handleHeader reconciles the new head with the persisted Eth1Data,
+// resolving any reorg before scanning for and applying new logs.
+func (s *SyncService) handleHeader(header *types.Header) error {
+	height := header.Number.Uint64()
+
+	// ParentHash is only comparable to the stored BlockHash when header is at
+	// most one block ahead of it: either it claims to extend the stored head
+	// (height == BlockHeight+1), in which case ParentHash is the hash of
+	// BlockHeight itself, or it arrives at or below the stored height,
+	// which can only mean a competing tip replaced what we already
+	// processed there. A header further ahead is an ordinary forward gap
+	// (startup catch-up or a resumed backfill): ParentHash is the hash of
+	// height-1, which was never recorded as BlockHeight, so comparing the
+	// two would misclassify every such gap as a reorg. That case is left to
+	// the ordinary range scan below.
+	if s.Eth1Data.BlockHeight != 0 && height <= s.Eth1Data.BlockHeight+1 && header.ParentHash != s.Eth1Data.BlockHash {
+		ancestor, err := s.findCommonAncestor(header)
+		if err != nil {
+			return fmt.Errorf("cannot resolve reorg: %w", err)
+		}
+		log.Warn("L1 reorg detected, rolling back", "ancestor", ancestor, "head", height)
+		if err := s.rollbackTo(ancestor); err != nil {
+			return fmt.Errorf("cannot roll back to ancestor %d: %w", ancestor, err)
+		}
+	}
+
+	start := s.Eth1Data.BlockHeight + 1
+	if err := s.applyLogsInRange(start, height); err != nil {
+		return err
+	}
+
+	if err := s.l1BlockHashes.Add(height, header.Hash()); err != nil {
+		return fmt.Errorf("cannot persist L1 block hash %d: %w", height, err)
+	}
+	s.Eth1Data = Eth1Data{BlockHeight: height, BlockHash: header.Hash()}
+	return s.writeLastProcessedEth1Data(s.Eth1Data)
+}
+
+// applyLogsInRange fetches and applies every CTC log between from and to,
+// inclusive, chunking the request into windows of at most s.logWindowSize
+// blocks to stay under the log-range limits L1 providers tend to impose.
+func (s *SyncService) applyLogsInRange(from, to uint64) error {
+	for windowFrom := from; windowFrom <= to; windowFrom += s.logWindowSize {
+		windowTo := windowFrom + s.logWindowSize - 1
+		if windowTo > to {
+			windowTo = to
+		}
+		if err := s.applyLogWindow(windowFrom, windowTo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyLog dispatches a single CTC log to its handler based on its topic.
+func (s *SyncService) applyLog(l types.Log) error {
+	if len(l.Topics) == 0 {
+		return nil
+	}
+	switch l.Topics[0] {
+	case common.BytesToHash(transactionEnqueuedEventSignature):
+		return s.applyTransactionEnqueued(l)
+	case common.BytesToHash(queueBatchAppendedEventSignature):
+		return s.applyQueueBatchAppended(l)
+	case common.BytesToHash(sequencerBatchAppendedEventSignature):
+		return s.applySequencerBatchAppended(l)
+	default:
+		return nil
+	}
+}
+
+// applyTransactionEnqueued decodes a TransactionEnqueued log and stores the
+// resulting RollupTransaction in the tx cache, keyed by its queue index.
+func (s *SyncService) applyTransactionEnqueued(l types.Log) error {
+	event, err := s.ctcFilterer.ParseTransactionEnqueued(l)
+	if err != nil {
+		return fmt.Errorf("cannot parse TransactionEnqueued: %w", err)
+	}
+
+	tx := types.NewTransaction(
+		0,
+		event.Target,
+		big.NewInt(0),
+		event.GasLimit.Uint64(),
+		big.NewInt(0),
+		event.Data,
+		&event.L1TxOrigin,
+		new(big.Int).SetUint64(l.BlockNumber),
+		types.QueueOriginL1ToL2,
+		types.SighashEIP155,
+	)
+
+	rtx := &RollupTransaction{
+		tx:          tx,
+		timestamp:   time.Unix(event.Timestamp.Int64(), 0),
+		blockHeight: l.BlockNumber,
+		blockHash:   l.BlockHash,
+		queueIndex:  event.QueueIndex.Uint64(),
+	}
+	// A resumed backfill can re-filter a window whose logs were already
+	// applied before a crash; (queueIndex, l1BlockHash) identifies the same
+	// TransactionEnqueued log seen twice, so skip it rather than re-cache it.
+	if existing, ok := s.txCache.Load(rtx.queueIndex); ok && existing.blockHash == rtx.blockHash {
+		return nil
+	}
+	s.txCache.Store(rtx.queueIndex, rtx)
+	return nil
+}
+
+// applyQueueBatchAppended looks up every transaction referenced by the batch
+// in the tx cache and submits it to the local mempool, signed by the
+// ingestion key. The cached entries are left in place, not deleted.
+//
+// A resumed backfill re-filters and re-applies the whole window a crash
+// happened in, since the tx cache it depends on is not itself persisted.
+// TotalElements is the CTC's own cumulative element count, so once at least
+// one batch has been committed, comparing it against the last watermark
+// this service committed tells us whether this exact log was already
+// fully applied before the crash; queueIndex is checked the same way per
+// element, as a second line of defense if the crash happened partway
+// through this very batch.
+func (s *SyncService) applyQueueBatchAppended(l types.Log) error {
+	event, err := s.ctcFilterer.ParseQueueBatchAppended(l)
+	if err != nil {
+		return fmt.Errorf("cannot parse QueueBatchAppended: %w", err)
+	}
+
+	totalElements := event.TotalElements.Uint64()
+	if s.batchCount > 0 && totalElements <= s.lastBatchTotalElements {
+		return nil
+	}
+
+	start := event.StartingQueueIndex.Uint64()
+	count := event.NumQueueElements.Uint64()
+	for i := uint64(0); i < count; i++ {
+		queueIndex := start + i
+		rtx, ok := s.txCache.Load(queueIndex)
+		if !ok {
+			return fmt.Errorf("queue index %d missing from tx cache", queueIndex)
+		}
+		if queueIndex >= s.nextQueueIndex {
+			if err := s.applyTransaction(rtx.tx); err != nil {
+				return err
+			}
+			s.markExecuted(queueIndex)
+		}
+		s.advanceQueueIndex(queueIndex)
+	}
+	s.batchCount++
+	s.lastBatchTotalElements = totalElements
+	return nil
+}
+
+// applySequencerBatchAppended decodes the appendSequencerBatch calldata of
+// the L1 transaction that emitted l, and replays its elements in order:
+// queued references are looked up in the tx cache and signed with the
+// ingestion key exactly as in applyQueueBatchAppended, while sequencer-signed
+// elements are already-valid L2 transactions and are submitted as-is.
+//
+// See applyQueueBatchAppended for why TotalElements gates the whole batch:
+// sequencer-signed elements carry no queue index to check individually, so
+// that whole-batch watermark is the only thing preventing a resumed
+// backfill from resubmitting them.
+func (s *SyncService) applySequencerBatchAppended(l types.Log) (err error) {
+	event, err := s.ctcFilterer.ParseSequencerBatchAppended(l)
+	if err != nil {
+		return fmt.Errorf("cannot parse SequencerBatchAppended: %w", err)
+	}
+
+	totalElements := event.TotalElements.Uint64()
+	if s.batchCount > 0 && totalElements <= s.lastBatchTotalElements {
+		return nil
+	}
+
+	l1tx, isPending, err := s.ethclient.TransactionByHash(s.ctx, l.TxHash)
+	if err != nil {
+		return fmt.Errorf("cannot fetch L1 transaction %s: %w", l.TxHash, err)
+	}
+	if isPending {
+		return fmt.Errorf("L1 transaction %s is still pending", l.TxHash)
+	}
+
+	// appendSequencerBatch calldata is prefixed with the 4-byte method
+	// selector; only the payload after it uses the custom wire format.
+	calldata := l1tx.Data()
+	if len(calldata) < 4 {
+		return fmt.Errorf("L1 transaction %s has no appendSequencerBatch calldata", l.TxHash)
+	}
+	elements, err := decodeAppendSequencerBatchCalldata(calldata[4:])
+	if err != nil {
+		return fmt.Errorf("cannot decode appendSequencerBatch calldata: %w", err)
+	}
+
+	// A sequencer-signed element carries nothing to individually recognize a
+	// replay by, so a batch retried after a mid-batch failure resubmits
+	// every sequencer-signed element from the start, including ones already
+	// verified before the failure; in verifier mode that would otherwise
+	// leave nextVerifyHeight pointing past the height those elements
+	// actually belong to. A queued element, by contrast, is individually
+	// deduped by nextQueueIndex and so is *not* resubmitted on retry once it
+	// has succeeded -- rolling nextVerifyHeight back past one would leave it
+	// permanently short, since nothing will ever advance it for that element
+	// again. safeVerifyHeight therefore only ratchets forward past a queued
+	// element's success, and any error restores nextVerifyHeight to it
+	// rather than all the way back to the start of the batch.
+	safeVerifyHeight := s.nextVerifyHeight
+	defer func() {
+		if err != nil {
+			s.nextVerifyHeight = safeVerifyHeight
+		}
+	}()
+
+	queueIndex := event.StartingQueueIndex.Uint64()
+	for _, element := range elements {
+		switch element.kind {
+		case sequencerBatchElementQueued:
+			rtx, ok := s.txCache.Load(queueIndex)
+			if !ok {
+				return fmt.Errorf("queue index %d missing from tx cache", queueIndex)
+			}
+			if queueIndex >= s.nextQueueIndex {
+				if err := s.applyTransaction(rtx.tx); err != nil {
+					return err
+				}
+				s.markExecuted(queueIndex)
+				safeVerifyHeight = s.nextVerifyHeight
+			}
+			s.advanceQueueIndex(queueIndex)
+			queueIndex++
+		case sequencerBatchElementSequencer:
+			if err := s.submitTransaction(element.tx); err != nil {
+				return err
+			}
+		}
+	}
+	s.batchCount++
+	s.lastBatchTotalElements = totalElements
+	return nil
+}
+
+// advanceQueueIndex records that queueIndex has been consumed out of the tx
+// cache, bumping nextQueueIndex if this is the furthest one processed so far.
+func (s *SyncService) advanceQueueIndex(queueIndex uint64) {
+	if queueIndex+1 > s.nextQueueIndex {
+		s.nextQueueIndex = queueIndex + 1
+	}
+}
+
+// markExecuted records that the L1->L2 message at queueIndex was applied via
+// submitTransaction while in verifier mode, i.e. actually replayed against
+// the local chain rather than merely handed to the mempool. It is a no-op
+// outside verifier mode.
+func (s *SyncService) markExecuted(queueIndex uint64) {
+	if s.verifier {
+		s.executedQueueIndices.Store(queueIndex, struct{}{})
+	}
+}
+
+// applyTransaction signs tx with the ingestion key, using the current nonce
+// of the signing address, and adds it to the local mempool.
+func (s *SyncService) applyTransaction(tx *types.Transaction) error {
+	nonce := s.txpool.Nonce(s.address)
+	unsigned := types.NewTransaction(
+		nonce,
+		*tx.To(),
+		tx.Value(),
+		tx.Gas(),
+		tx.GasPrice(),
+		tx.Data(),
+		tx.L1MessageSender(),
+		tx.L1BlockNumber(),
+		tx.QueueOrigin(),
+		types.SighashEIP155,
+	)
+	signed, err := types.SignTx(unsigned, types.NewEIP155Signer(s.bc.Config().ChainID), key)
+	if err != nil {
+		return fmt.Errorf("cannot sign transaction: %w", err)
+	}
+	return s.submitTransaction(signed)
+}
+
+// submitTransaction hands tx off to either the local mempool or, in
+// verifier mode, to the verifier for direct execution against the chain.
+func (s *SyncService) submitTransaction(tx *types.Transaction) error {
+	if s.verifier {
+		return s.verifyTransaction(tx)
+	}
+	return s.txpool.AddLocal(tx)
+}
+
+// IsSyncing reports whether the sync service is still catching up on
+// historical CTC events. This mirrors the pattern used for transaction
+// indexing, where `eth.syncing` is kept true until indexing has caught up
+// even after the local L1 node itself has finished syncing.
+func (s *SyncService) IsSyncing() bool {
+	return s.Eth1Data.BlockHeight < s.highestL1Block()
+}
+
+// highestL1Block returns the highest known L1 block height, preferring the
+// connected node's own sync progress and falling back to the last processed
+// height when no better information is available.
+func (s *SyncService) highestL1Block() uint64 {
+	progress, err := s.ethclient.SyncProgress(s.ctx)
+	if err != nil || progress == nil || progress.HighestBlock < s.Eth1Data.BlockHeight {
+		return s.Eth1Data.BlockHeight
+	}
+	return progress.HighestBlock
+}
+
+// GetLastProcessedEth1Data reads the last persisted L1 checkpoint from the
+// database. It returns the zero value if none has been written yet.
+func (s *SyncService) GetLastProcessedEth1Data() Eth1Data {
+	raw, err := s.db.Get(lastProcessedEth1DataKey)
+	if err != nil || len(raw) != 40 {
+		return Eth1Data{}
+	}
+	return Eth1Data{
+		BlockHeight: binary.BigEndian.Uint64(raw[:8]),
+		BlockHash:   common.BytesToHash(raw[8:]),
+	}
+}
+
+// writeLastProcessedEth1Data persists data as the last processed L1
+// checkpoint.
+func (s *SyncService) writeLastProcessedEth1Data(data Eth1Data) error {
+	raw := make([]byte, 40)
+	binary.BigEndian.PutUint64(raw[:8], data.BlockHeight)
+	copy(raw[8:], data.BlockHash.Bytes())
+	return s.db.Put(lastProcessedEth1DataKey, raw)
+}
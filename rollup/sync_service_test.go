@@ -3,6 +3,7 @@ package rollup
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"math/big"
 	"testing"
@@ -18,7 +19,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Mock deployed address of canonical transaction chain
@@ -186,6 +189,57 @@ func TestSyncServiceTransactionEnqueued(t *testing.T) {
 	}
 }
 
+// Tests that re-applying the exact same TransactionEnqueued log -- as a
+// resumed backfill does when it re-filters the window a crash happened in
+// -- is recognized as the log already cached for that queue index and
+// skipped, rather than clobbering the cached entry.
+func TestSyncServiceTransactionEnqueuedIdempotentOnResume(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockEthClient(service)
+	mockLogClient(service, [][]types.Log{})
+
+	queueIndex := big.NewInt(0)
+	timestamp := big.NewInt(24)
+	target := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	gasLimit := big.NewInt(66)
+	data := []byte{0x02, 0x92}
+
+	l := types.Log{
+		Address:     ctcAddress,
+		BlockNumber: 1,
+		BlockHash:   common.HexToHash("0xaa"),
+		Topics: []common.Hash{
+			common.BytesToHash(transactionEnqueuedEventSignature),
+		},
+		Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, queueIndex, timestamp, data),
+	}
+
+	if err := service.applyTransactionEnqueued(l); err != nil {
+		t.Fatal(err)
+	}
+	first, ok := service.txCache.Load(queueIndex.Uint64())
+	if !ok {
+		t.Fatal("Transaction not found in cache")
+	}
+
+	// A resumed backfill re-filters the same window and re-observes the
+	// identical log.
+	if err := service.applyTransactionEnqueued(l); err != nil {
+		t.Fatal(err)
+	}
+	second, ok := service.txCache.Load(queueIndex.Uint64())
+	if !ok {
+		t.Fatal("Transaction missing from cache after re-applying the same log")
+	}
+	if second != first {
+		t.Fatal("Re-applying the same TransactionEnqueued log replaced the cached entry instead of being skipped")
+	}
+}
+
 // Tests that a queue batch append results in the transaction
 // from the cache is played against the state.
 func TestSyncServiceQueueBatchAppend(t *testing.T) {
@@ -283,13 +337,787 @@ func TestSyncServiceQueueBatchAppend(t *testing.T) {
 	}
 }
 
+// encodeSingleSequencerElementCalldata builds calldata matching the wire
+// format expected by decodeAppendSequencerBatchCalldata: a 4-byte method
+// selector followed by a single sequencer-signed transaction, with no queued
+// references. It is used to exercise SequencerBatchAppended replay, where
+// (unlike a queued reference) nothing but the batch-level TotalElements
+// watermark stops a resumed backfill from resubmitting the element.
+func encodeSingleSequencerElementCalldata(t *testing.T, seqTx *types.Transaction) []byte {
+	payload, err := rlp.EncodeToBytes(seqTx)
+	if err != nil {
+		t.Fatalf("Cannot RLP encode sequencer tx: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xde, 0xad, 0xbe, 0xef}) // method selector, unused by the decoder
+
+	var numElements [3]byte
+	numElements[0], numElements[1], numElements[2] = 0, 0, 1
+	buf.Write(numElements[:])
+
+	buf.WriteByte(byte(sequencerBatchElementSequencer))
+	var header [21]byte
+	binary.BigEndian.PutUint64(header[0:8], 97538)                           // timestamp
+	binary.BigEndian.PutUint64(header[8:16], seqTx.L1BlockNumber().Uint64()) // blockNumber
+	header[16] = 0                                                           // txType
+	binary.BigEndian.PutUint32(header[17:21], uint32(len(payload)))          // payload length
+	buf.Write(header[:])
+	buf.Write(payload)
+
+	return buf.Bytes()
+}
+
+// Tests that a SyncService restarted against the same database after a
+// crash resumes from the persisted checkpoint without resubmitting a batch
+// it had already fully committed, while still applying a new batch that
+// follows it in the re-scanned window.
+//
+// This exercises the TotalElements watermark specifically via a
+// SequencerBatchAppended batch, since a sequencer-signed element (unlike a
+// queued reference) carries no queue index of its own for a per-element
+// check to fall back on; the watermark is the only thing standing between a
+// resumed backfill and a duplicate mempool submission.
+func TestSyncServiceResumesAfterCrash(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	// Constructing a SyncService sets the package-level god key used to sign
+	// below; do this before building the sequencer-signed transactions.
+	before, err := newTestSyncServiceWithDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := types.NewEIP155Signer(big.NewInt(420))
+	firstTarget := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	secondTarget := common.HexToAddress("0x000000000000000000000000000000deadbeef")
+
+	firstSeqTx, err := types.SignTx(
+		types.NewTransaction(0, firstTarget, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(1), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The restarted service gets a fresh mempool and nothing was mined, so
+	// the second transaction uses nonce 0 to be pending rather than queued.
+	secondSeqTx, err := types.SignTx(
+		types.NewTransaction(0, secondTarget, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(2), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstL1Tx := types.NewTransaction(0, ctcAddress, big.NewInt(0), 1000000, big.NewInt(0), encodeSingleSequencerElementCalldata(t, firstSeqTx), nil, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	secondL1Tx := types.NewTransaction(1, ctcAddress, big.NewInt(0), 1000000, big.NewInt(0), encodeSingleSequencerElementCalldata(t, secondSeqTx), nil, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	firstBatchLog := types.Log{
+		Address:     ctcAddress,
+		BlockNumber: 1,
+		TxHash:      firstL1Tx.Hash(),
+		Topics: []common.Hash{
+			common.BytesToHash(sequencerBatchAppendedEventSignature),
+		},
+		Data: abiEncodeQueueBatchAppended(big.NewInt(0), big.NewInt(0), big.NewInt(1)),
+	}
+	secondBatchLog := types.Log{
+		Address:     ctcAddress,
+		BlockNumber: 2,
+		TxHash:      secondL1Tx.Hash(),
+		Topics: []common.Hash{
+			common.BytesToHash(sequencerBatchAppendedEventSignature),
+		},
+		Data: abiEncodeQueueBatchAppended(big.NewInt(0), big.NewInt(0), big.NewInt(2)),
+	}
+
+	transactionsByHash := map[common.Hash]*types.Transaction{
+		firstL1Tx.Hash():  firstL1Tx,
+		secondL1Tx.Hash(): secondL1Tx,
+	}
+
+	// The first service fully commits the first batch before "crashing":
+	// both its log checkpoint (batchCount, lastBatchTotalElements) and its
+	// mempool submission complete.
+	before.ethclient = &mockEthereumClient{transactionsByHash: transactionsByHash}
+	mockLogClient(before, [][]types.Log{{firstBatchLog}})
+	if err := before.applyLogsInRange(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if before.batchCount == 0 {
+		t.Fatal("Expected the first batch to be committed before the crash")
+	}
+	if pending, _ := before.txpool.Pending(); len(pending) != 1 {
+		t.Fatal("Expected exactly one mempool entry before the crash")
+	}
+
+	// A fresh SyncService against the same database resumes from the
+	// persisted checkpoint and re-scans a window that starts before the
+	// already-committed batch: it must recognize and skip the replayed
+	// batch while still applying the new one that follows it.
+	after, err := newTestSyncServiceWithDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.batchCount == 0 {
+		t.Fatal("Expected the restored checkpoint to reflect the batch committed before the crash")
+	}
+	after.ethclient = &mockEthereumClient{transactionsByHash: transactionsByHash}
+	mockLogClient(after, [][]types.Log{{firstBatchLog, secondBatchLog}})
+	if err := after.applyLogsInRange(1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, _ := after.txpool.Pending()
+	count := 0
+	for _, txs := range pending {
+		count += len(txs)
+	}
+	if count != 1 {
+		t.Fatalf("Expected exactly one mempool entry after resuming (no duplicate of the pre-crash batch), got %d", count)
+	}
+	secondSender, err := types.Sender(signer, secondSeqTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs, ok := pending[secondSender]; !ok || len(txs) != 1 || !bytes.Equal(txs[0].Hash().Bytes(), secondSeqTx.Hash().Bytes()) {
+		t.Fatal("Expected the new batch's transaction, and only that one, to land in the mempool")
+	}
+}
+
+// Tests that a resumed service which receives a head far ahead of its
+// restored checkpoint (the ordinary startup/catch-up case, and exactly what
+// a resumed backfill looks like) scans the whole intervening range instead
+// of misdetecting it as an L1 reorg and rolling back to a recent ancestor,
+// which would silently drop every CTC log in between.
+func TestSyncServiceResumesWithForwardGap(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	before, err := newTestSyncServiceWithDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockEthClient(before)
+	mockLogClient(before, [][]types.Log{{}})
+
+	go before.Loop()
+	before.heads <- &types.Header{Number: big.NewInt(1)}
+	_ = <-before.doneProcessing
+	if before.Eth1Data.BlockHeight != 1 {
+		t.Fatal("Expected the checkpoint to be at height 1 before the crash")
+	}
+
+	// A fresh service against the same database resumes from height 1, then
+	// receives a head far ahead of it, as if the node had been down while L1
+	// advanced well past the last processed block.
+	after, err := newTestSyncServiceWithDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Eth1Data.BlockHeight != 1 {
+		t.Fatal("Expected the restored checkpoint to reflect the block processed before the crash")
+	}
+
+	queueIndex := big.NewInt(0)
+	timestamp := big.NewInt(24)
+	target := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	gasLimit := big.NewInt(66)
+	data := []byte{0x02, 0x92}
+
+	mockEthClient(after)
+	mockLogClient(after, [][]types.Log{
+		{
+			{
+				Address:     ctcAddress,
+				BlockNumber: 25,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, queueIndex, timestamp, data),
+			},
+		},
+	})
+
+	go after.Loop()
+	after.heads <- &types.Header{Number: big.NewInt(50)}
+
+	select {
+	case err := <-after.errCh:
+		t.Fatalf("Forward gap was misdetected as a reorg: %v", err)
+	case height := <-after.doneProcessing:
+		if height != 50 {
+			t.Fatalf("Wrong height received: got %d, expected 50", height)
+		}
+	}
+
+	if after.Eth1Data.BlockHeight != 50 {
+		t.Fatalf("Expected the checkpoint to advance to height 50, got %d", after.Eth1Data.BlockHeight)
+	}
+	if _, ok := after.txCache.Load(queueIndex.Uint64()); !ok {
+		t.Fatal("Transaction enqueued in the gap between the checkpoint and the new head was lost")
+	}
+}
+
+// Tests that a reorg on L1 (a header with a different hash arriving at a
+// height already processed) rolls the persisted Eth1Data and the tx cache
+// back to the common ancestor, purging anything observed on the orphaned
+// branch.
+func TestSyncServiceReorg(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queueIndex := big.NewInt(0)
+	timestamp := big.NewInt(24)
+	target := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	gasLimit := big.NewInt(66)
+	data := []byte{0x02, 0x92}
+
+	mockEthClient(service)
+	mockLogClient(service, [][]types.Log{
+		{
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, queueIndex, timestamp, data),
+			},
+		},
+		// The reorged branch does not contain the enqueued transaction.
+		{},
+	})
+
+	go service.Loop()
+
+	service.heads <- &types.Header{Number: big.NewInt(1)}
+	_ = <-service.doneProcessing
+
+	if _, ok := service.txCache.Load(queueIndex.Uint64()); !ok {
+		t.Fatal("Transaction not found in cache before reorg")
+	}
+
+	// Feed a header at the same height but with a different hash, simulating
+	// an L1 reorg.
+	service.heads <- &types.Header{Number: big.NewInt(1), Extra: []byte{0x01}}
+	_ = <-service.doneProcessing
+
+	if _, ok := service.txCache.Load(queueIndex.Uint64()); ok {
+		t.Fatal("Transaction cache was not purged on reorg")
+	}
+}
+
+// encodeAppendSequencerBatchCalldata builds calldata matching the wire
+// format expected by decodeAppendSequencerBatchCalldata: a 4-byte method
+// selector (its value is irrelevant to decoding) followed by a single
+// queued reference and a single sequencer-signed transaction.
+func encodeAppendSequencerBatchCalldata(t *testing.T, seqTx *types.Transaction) []byte {
+	payload, err := rlp.EncodeToBytes(seqTx)
+	if err != nil {
+		t.Fatalf("Cannot RLP encode sequencer tx: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xde, 0xad, 0xbe, 0xef}) // method selector, unused by the decoder
+
+	var numElements [3]byte
+	numElements[0], numElements[1], numElements[2] = 0, 0, 2
+	buf.Write(numElements[:])
+
+	// Element 0: a reference into the already-enqueued queue.
+	buf.WriteByte(byte(sequencerBatchElementQueued))
+
+	// Element 1: a sequencer-signed transaction.
+	buf.WriteByte(byte(sequencerBatchElementSequencer))
+	var header [21]byte
+	binary.BigEndian.PutUint64(header[0:8], 97538)                  // timestamp
+	binary.BigEndian.PutUint64(header[8:16], 2)                     // blockNumber
+	header[16] = 0                                                  // txType
+	binary.BigEndian.PutUint32(header[17:21], uint32(len(payload))) // payload length
+	buf.Write(header[:])
+	buf.Write(payload)
+
+	return buf.Bytes()
+}
+
+// Tests that a SequencerBatchAppended event mixing a queued reference and a
+// sequencer-signed transaction results in both landing in the mempool, each
+// under its own sender. Their relative order is not checked.
+func TestSyncServiceSequencerBatchAppended(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queueIndex, timestamp, gasLimit := big.NewInt(0), big.NewInt(97538), big.NewInt(210000)
+	target := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	data := []byte{0x02, 0x92}
+
+	// The sequencer-signed transaction uses its own key, so that it does not
+	// collide at nonce 0 with the queued transaction signed by the god key.
+	sequencerKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := types.NewEIP155Signer(big.NewInt(420))
+	seqTx, err := types.SignTx(
+		types.NewTransaction(0, target, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(2), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, sequencerKey,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calldata := encodeAppendSequencerBatchCalldata(t, seqTx)
+	l1tx := types.NewTransaction(0, ctcAddress, big.NewInt(0), 1000000, big.NewInt(0), calldata, nil, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+
+	client := newMockEthereumClient()
+	client.transactionByHash = l1tx
+	service.ethclient = client
+
+	startingQueueIndex, numQueueElements, totalElements := big.NewInt(0), big.NewInt(1), big.NewInt(2)
+
+	mockLogClient(service, [][]types.Log{
+		{
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, queueIndex, timestamp, data),
+			},
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				TxHash:      l1tx.Hash(),
+				Topics: []common.Hash{
+					common.BytesToHash(sequencerBatchAppendedEventSignature),
+				},
+				Data: abiEncodeQueueBatchAppended(startingQueueIndex, numQueueElements, totalElements),
+			},
+		},
+	})
+
+	go service.Loop()
+	service.heads <- &types.Header{Number: big.NewInt(1)}
+	_ = <-service.doneProcessing
+
+	pending, _ := service.txpool.Pending()
+
+	queuedTxs, ok := pending[service.address]
+	if !ok || len(queuedTxs) != 1 {
+		t.Fatal("Queued transaction did not land in the mempool")
+	}
+
+	sequencerSender, err := types.Sender(signer, seqTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequencerTxs, ok := pending[sequencerSender]
+	if !ok || len(sequencerTxs) != 1 {
+		t.Fatal("Sequencer-signed transaction did not land in the mempool")
+	}
+	if !bytes.Equal(sequencerTxs[0].Hash().Bytes(), seqTx.Hash().Bytes()) {
+		t.Fatal("Sequencer transaction hash mismatch")
+	}
+}
+
+// Tests that, in verifier mode, replaying a queued transaction that was
+// doctored in the L1 log (relative to what was actually mined into the
+// chain) is detected as a RollupDivergence.
+func TestSyncServiceVerifierDetectsDivergence(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queueIndex, timestamp, gasLimit := big.NewInt(0), big.NewInt(24), big.NewInt(66)
+	honestTarget := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	doctoredTarget := common.HexToAddress("0x000000000000000000000000000000deadbeef")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	data := []byte{0x02, 0x92}
+
+	// Build the transaction that was honestly mined into the chain: exactly
+	// what applyTransaction would produce for the un-doctored log.
+	honestTx, err := types.SignTx(
+		types.NewTransaction(0, honestTarget, big.NewInt(0), gasLimit.Uint64(), big.NewInt(0), data, &l1TxOrigin, big.NewInt(1), types.QueueOriginL1ToL2, types.SighashEIP155),
+		types.NewEIP155Signer(big.NewInt(420)), key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainCfg := params.AllEthashProtocolChanges
+	chainCfg.ChainID = big.NewInt(420)
+	blocks, _ := core.GenerateChain(chainCfg, service.bc.Genesis(), ethash.NewFaker(), service.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(honestTx)
+	})
+	if _, err := service.bc.InsertChain(blocks); err != nil {
+		t.Fatalf("Cannot seed chain: %v", err)
+	}
+
+	// Put the service into verifier mode and feed it a doctored log that
+	// disagrees with what was actually mined.
+	service.verifier = true
+	mockEthClient(service)
+	mockLogClient(service, [][]types.Log{
+		{
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &doctoredTarget, gasLimit, queueIndex, timestamp, data),
+			},
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(queueBatchAppendedEventSignature),
+				},
+				Data: abiEncodeQueueBatchAppended(big.NewInt(0), big.NewInt(1), big.NewInt(0)),
+			},
+		},
+	})
+
+	go service.Loop()
+	service.heads <- &types.Header{Number: big.NewInt(1)}
+
+	select {
+	case err := <-service.Err():
+		if _, ok := err.(*RollupDivergence); !ok {
+			t.Fatalf("Expected a RollupDivergence, got: %v", err)
+		}
+	case <-service.doneProcessing:
+		t.Fatal("Expected the verifier to detect a divergence, but the header was processed cleanly")
+	}
+}
+
+// encodeTwoSequencerElementsCalldata builds appendSequencerBatch calldata
+// containing exactly two sequencer-signed elements, back to back.
+func encodeTwoSequencerElementsCalldata(t *testing.T, first, second *types.Transaction) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xde, 0xad, 0xbe, 0xef}) // method selector, unused by the decoder
+
+	var numElements [3]byte
+	numElements[0], numElements[1], numElements[2] = 0, 0, 2
+	buf.Write(numElements[:])
+
+	for _, tx := range []*types.Transaction{first, second} {
+		payload, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatalf("Cannot RLP encode sequencer tx: %v", err)
+		}
+		buf.WriteByte(byte(sequencerBatchElementSequencer))
+		var header [21]byte
+		binary.BigEndian.PutUint64(header[0:8], 97538) // timestamp
+		binary.BigEndian.PutUint64(header[8:16], tx.L1BlockNumber().Uint64())
+		header[16] = 0                                                  // txType
+		binary.BigEndian.PutUint32(header[17:21], uint32(len(payload))) // payload length
+		buf.Write(header[:])
+		buf.Write(payload)
+	}
+
+	return buf.Bytes()
+}
+
+// Tests that, in verifier mode, a SequencerBatchAppended batch that fails
+// partway through (here because the second element's block has not been
+// synced locally yet) does not leave nextVerifyHeight pointing past the
+// height its first, already-verified element actually belongs to. A
+// resumed backfill retries the whole batch from scratch -- including
+// elements already verified before the failure -- so the retry must
+// re-verify the first element against the same height as before, not the
+// one after it.
+func TestSyncServiceVerifierResumesMidBatchFailure(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainCfg := params.AllEthashProtocolChanges
+	chainCfg.ChainID = big.NewInt(420)
+	signer := types.NewEIP155Signer(big.NewInt(420))
+
+	firstTarget := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	secondTarget := common.HexToAddress("0x000000000000000000000000000000deadbeef")
+	firstTx, err := types.SignTx(
+		types.NewTransaction(0, firstTarget, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(1), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondTx, err := types.SignTx(
+		types.NewTransaction(1, secondTarget, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(1), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first element's block has been synced locally so far; the
+	// second does not exist yet.
+	firstBlocks, _ := core.GenerateChain(chainCfg, service.bc.Genesis(), ethash.NewFaker(), service.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(firstTx)
+	})
+	if _, err := service.bc.InsertChain(firstBlocks); err != nil {
+		t.Fatalf("Cannot seed chain: %v", err)
+	}
+
+	l1tx := types.NewTransaction(0, ctcAddress, big.NewInt(0), 1000000, big.NewInt(0), encodeTwoSequencerElementsCalldata(t, firstTx, secondTx), nil, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	batchLog := types.Log{
+		Address:     ctcAddress,
+		BlockNumber: 1,
+		TxHash:      l1tx.Hash(),
+		Topics: []common.Hash{
+			common.BytesToHash(sequencerBatchAppendedEventSignature),
+		},
+		Data: abiEncodeQueueBatchAppended(big.NewInt(0), big.NewInt(0), big.NewInt(2)),
+	}
+
+	service.verifier = true
+	mockLogClient(service, [][]types.Log{})
+	service.ethclient = &mockEthereumClient{transactionsByHash: map[common.Hash]*types.Transaction{l1tx.Hash(): l1tx}}
+
+	if err := service.applySequencerBatchAppended(batchLog); err == nil {
+		t.Fatal("Expected the batch to fail since the second element's block is not synced yet")
+	}
+	if service.nextVerifyHeight != 1 {
+		t.Fatalf("Expected nextVerifyHeight to be rolled back to 1 after the failed batch, got %d", service.nextVerifyHeight)
+	}
+	if service.batchCount != 0 {
+		t.Fatal("Expected the batch watermark to remain uncommitted after the failed batch")
+	}
+
+	// The second element's block lands locally before the retry.
+	secondBlocks, _ := core.GenerateChain(chainCfg, firstBlocks[0], ethash.NewFaker(), service.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(secondTx)
+	})
+	if _, err := service.bc.InsertChain(secondBlocks); err != nil {
+		t.Fatalf("Cannot extend chain: %v", err)
+	}
+
+	// A resumed backfill re-filters the same window and retries the whole
+	// batch, including the first element that already verified cleanly.
+	if err := service.applySequencerBatchAppended(batchLog); err != nil {
+		t.Fatalf("Expected the retried batch to verify cleanly, got: %v", err)
+	}
+	if service.nextVerifyHeight != 3 {
+		t.Fatalf("Expected nextVerifyHeight to advance past both blocks, got %d", service.nextVerifyHeight)
+	}
+	if service.batchCount != 1 {
+		t.Fatal("Expected the batch watermark to commit after the retried batch succeeded")
+	}
+}
+
+// Tests that, in verifier mode, a batch mixing a queued element with a
+// sequencer-signed element that fails to verify does not roll
+// nextVerifyHeight back past the queued element's success: the queued
+// element is individually deduped by nextQueueIndex and so is skipped, not
+// re-verified, on retry, and rolling back past it would leave
+// nextVerifyHeight permanently short of the height the retried
+// sequencer-signed element actually belongs to.
+func TestSyncServiceVerifierResumesMidBatchFailureAfterQueuedElement(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainCfg := params.AllEthashProtocolChanges
+	chainCfg.ChainID = big.NewInt(420)
+	signer := types.NewEIP155Signer(big.NewInt(420))
+
+	queuedTarget := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	gasLimit := big.NewInt(66)
+	data := []byte{0x02, 0x92}
+
+	// The queued element's unsigned transaction, exactly as
+	// applyTransactionEnqueued would have cached it.
+	queuedTx := types.NewTransaction(0, queuedTarget, big.NewInt(0), gasLimit.Uint64(), big.NewInt(0), data, &l1TxOrigin, big.NewInt(1), types.QueueOriginL1ToL2, types.SighashEIP155)
+	service.txCache.Store(0, &RollupTransaction{tx: queuedTx, queueIndex: 0, blockHeight: 1})
+
+	// What applyTransaction produces for it: signed with the ingestion key
+	// at nonce 0, since verifier mode never touches the txpool's nonce
+	// tracking.
+	honestQueuedTx, err := types.SignTx(queuedTx, signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sequencerTarget := common.HexToAddress("0x000000000000000000000000000000deadbeef")
+	sequencerTx, err := types.SignTx(
+		types.NewTransaction(1, sequencerTarget, big.NewInt(0), 21000, big.NewInt(0), nil, nil, big.NewInt(2), types.QueueOriginSequencer, types.SighashEIP155),
+		signer, key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the queued element's block has been synced locally so far; the
+	// sequencer-signed element's does not exist yet.
+	firstBlocks, _ := core.GenerateChain(chainCfg, service.bc.Genesis(), ethash.NewFaker(), service.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(honestQueuedTx)
+	})
+	if _, err := service.bc.InsertChain(firstBlocks); err != nil {
+		t.Fatalf("Cannot seed chain: %v", err)
+	}
+
+	calldata := encodeAppendSequencerBatchCalldata(t, sequencerTx)
+	l1tx := types.NewTransaction(0, ctcAddress, big.NewInt(0), 1000000, big.NewInt(0), calldata, nil, nil, types.QueueOriginL1ToL2, types.SighashEIP155)
+	batchLog := types.Log{
+		Address:     ctcAddress,
+		BlockNumber: 1,
+		TxHash:      l1tx.Hash(),
+		Topics: []common.Hash{
+			common.BytesToHash(sequencerBatchAppendedEventSignature),
+		},
+		Data: abiEncodeQueueBatchAppended(big.NewInt(0), big.NewInt(1), big.NewInt(2)),
+	}
+
+	service.verifier = true
+	mockLogClient(service, [][]types.Log{})
+	service.ethclient = &mockEthereumClient{transactionsByHash: map[common.Hash]*types.Transaction{l1tx.Hash(): l1tx}}
+
+	if err := service.applySequencerBatchAppended(batchLog); err == nil {
+		t.Fatal("Expected the batch to fail since the sequencer-signed element's block is not synced yet")
+	}
+	if service.nextVerifyHeight != 2 {
+		t.Fatalf("Expected nextVerifyHeight to hold at 2 (past the queued element's success), got %d", service.nextVerifyHeight)
+	}
+	if service.nextQueueIndex != 1 {
+		t.Fatalf("Expected the queued element to be durably consumed, got nextQueueIndex %d", service.nextQueueIndex)
+	}
+
+	// The sequencer-signed element's block lands locally before the retry.
+	secondBlocks, _ := core.GenerateChain(chainCfg, firstBlocks[0], ethash.NewFaker(), service.db, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(sequencerTx)
+	})
+	if _, err := service.bc.InsertChain(secondBlocks); err != nil {
+		t.Fatalf("Cannot extend chain: %v", err)
+	}
+
+	// A resumed backfill re-filters the same window and retries the whole
+	// batch; the queued element is skipped via the nextQueueIndex dedup, so
+	// only the sequencer-signed element is actually re-verified.
+	if err := service.applySequencerBatchAppended(batchLog); err != nil {
+		t.Fatalf("Expected the retried batch to verify cleanly, got: %v", err)
+	}
+	if service.nextVerifyHeight != 3 {
+		t.Fatalf("Expected nextVerifyHeight to advance past both blocks, got %d", service.nextVerifyHeight)
+	}
+}
+
+// Tests that the `rollup` RPC namespace reflects the state of the tx cache
+// and the persisted Eth1Data as events are processed.
+func TestSyncServiceRollupAPI(t *testing.T) {
+	service, err := newTestSyncService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batchedQueueIndex, stillEnqueuedQueueIndex := big.NewInt(0), big.NewInt(1)
+	timestamp, gasLimit := big.NewInt(24), big.NewInt(66)
+	target := common.HexToAddress("0x04668ec2f57cc15c381b461b9fedab5d451c8f7f")
+	l1TxOrigin := common.HexToAddress("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8")
+	data := []byte{0x02, 0x92}
+
+	mockEthClient(service)
+	mockLogClient(service, [][]types.Log{
+		{
+			// This one is consumed by the batch below (but stays cached),
+			// so its status should read "batched".
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, batchedQueueIndex, timestamp, data),
+			},
+			// This one is never referenced by a batch, so its status should
+			// stay "enqueued".
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(transactionEnqueuedEventSignature),
+				},
+				Data: abiEncodeCTCEnqueued(&l1TxOrigin, &target, gasLimit, stillEnqueuedQueueIndex, timestamp, data),
+			},
+			{
+				Address:     ctcAddress,
+				BlockNumber: 1,
+				Topics: []common.Hash{
+					common.BytesToHash(queueBatchAppendedEventSignature),
+				},
+				Data: abiEncodeQueueBatchAppended(batchedQueueIndex, big.NewInt(1), big.NewInt(1)),
+			},
+		},
+	})
+
+	go service.Loop()
+	service.heads <- &types.Header{Number: big.NewInt(1)}
+	_ = <-service.doneProcessing
+
+	api := NewPublicRollupAPI(service)
+
+	status := api.SyncStatus()
+	if uint64(status.CurrentL1Block) != 1 {
+		t.Fatalf("Wrong currentL1Block: got %d", status.CurrentL1Block)
+	}
+	if uint64(status.TxCacheSize) != 2 {
+		t.Fatalf("Wrong txCacheSize: got %d", status.TxCacheSize)
+	}
+
+	enqueued, err := api.GetEnqueuedTransaction(hexutil.Uint64(stillEnqueuedQueueIndex.Uint64()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enqueued.Target.Bytes(), target.Bytes()) {
+		t.Fatal("Wrong target in enqueued transaction")
+	}
+	if !bytes.Equal(enqueued.Data, data) {
+		t.Fatal("Wrong data in enqueued transaction")
+	}
+
+	if status := api.GetL1ToL2MessageStatus(hexutil.Uint64(stillEnqueuedQueueIndex.Uint64())); status != MessageStatusEnqueued {
+		t.Fatalf("Expected message to be enqueued, got %s", status)
+	}
+	if status := api.GetL1ToL2MessageStatus(hexutil.Uint64(batchedQueueIndex.Uint64())); status != MessageStatusBatched {
+		t.Fatalf("Expected message to be batched, got %s", status)
+	}
+	if status := api.GetL1ToL2MessageStatus(hexutil.Uint64(2)); status != MessageStatusUnknown {
+		t.Fatalf("Expected unknown message status, got %s", status)
+	}
+
+	if _, err := api.GetEnqueuedTransaction(hexutil.Uint64(2)); err == nil {
+		t.Fatal("Expected an error for a queue index with no cached transaction")
+	}
+}
+
 func newTestSyncService() (*SyncService, error) {
+	return newTestSyncServiceWithDB(rawdb.NewMemoryDatabase())
+}
+
+// newTestSyncServiceWithDB is newTestSyncService, but against a caller-supplied
+// database. It lets a test restart a SyncService against the same persisted
+// state, as if the process had crashed and come back up.
+func newTestSyncServiceWithDB(db ethdb.Database) (*SyncService, error) {
 	chainCfg := params.AllEthashProtocolChanges
 	chainID := big.NewInt(420)
 	chainCfg.ChainID = chainID
 
 	engine := ethash.NewFaker()
-	db := rawdb.NewMemoryDatabase()
 	_ = new(core.Genesis).MustCommit(db)
 	chain, err := core.NewBlockChain(db, nil, chainCfg, engine, vm.Config{}, nil)
 	if err != nil {
@@ -329,7 +1157,14 @@ func mockEthClient(service *SyncService) {
 }
 
 // Test utilities
-type mockEthereumClient struct{}
+type mockEthereumClient struct {
+	// transactionByHash, when set, is returned by TransactionByHash
+	// regardless of the hash requested.
+	transactionByHash *types.Transaction
+	// transactionsByHash, when set, is consulted before transactionByHash so
+	// that a test can serve a different L1 transaction per requested hash.
+	transactionsByHash map[common.Hash]*types.Transaction
+}
 
 func (m *mockEthereumClient) ChainID(context.Context) (*big.Int, error) {
 	return big.NewInt(0), nil
@@ -345,7 +1180,13 @@ func (m *mockEthereumClient) HeaderByNumber(context.Context, *big.Int) (*types.H
 	h := types.Header{}
 	return &h, nil
 }
-func (m *mockEthereumClient) TransactionByHash(context.Context, common.Hash) (*types.Transaction, bool, error) {
+func (m *mockEthereumClient) TransactionByHash(_ context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if tx, ok := m.transactionsByHash[hash]; ok {
+		return tx, false, nil
+	}
+	if m.transactionByHash != nil {
+		return m.transactionByHash, false, nil
+	}
 	t := types.Transaction{}
 	return &t, false, nil
 }
@@ -392,4 +1233,4 @@ func newMockSubscription() *mockSubscription {
 	return &mockSubscription{
 		e: e,
 	}
-}
\ No newline at end of file
+}
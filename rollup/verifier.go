@@ -0,0 +1,108 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// RollupDivergence is returned by the verifier when replaying a decoded
+// transaction produces a state root that does not match the block already
+// stored at that height. Its arrival halts the sync loop, since it means
+// the sequencer being tracked can no longer be trusted.
+type RollupDivergence struct {
+	BlockNumber uint64
+	// TxIndex is the position of the offending transaction within the
+	// block. The OVM model packs exactly one transaction per L2 block, so
+	// today this is always 0; it is carried separately from BlockNumber so
+	// that a future multi-transaction block layout doesn't need a new field.
+	TxIndex      int
+	ExpectedRoot common.Hash
+	GotRoot      common.Hash
+	ExpectedHash common.Hash
+}
+
+func (d *RollupDivergence) Error() string {
+	return fmt.Sprintf(
+		"rollup divergence at block %d (tx %d): expected root %s, got %s",
+		d.BlockNumber, d.TxIndex, d.ExpectedRoot, d.GotRoot,
+	)
+}
+
+// verifyTransaction replays tx against the state of the block preceding
+// s.nextVerifyHeight, and checks that the resulting state root matches the
+// block already stored at that height -- which is expected to have already
+// been produced, from the same sequencer feed, by whatever block-production
+// path this verifier is double-checking. Each transaction is checked against
+// the block at its own height, advancing one block at a time, rather than
+// against whatever the chain head happens to be when it arrives: the OVM
+// model packs exactly one transaction per L2 block, so a stream of more than
+// one decoded transaction otherwise drifts out of step with the blocks it is
+// meant to verify. It is the verifier-mode counterpart to submitting tx to
+// the mempool.
+func (s *SyncService) verifyTransaction(tx *types.Transaction) error {
+	height := s.nextVerifyHeight
+	expected := s.bc.GetBlockByNumber(height)
+	if expected == nil {
+		return fmt.Errorf("verifier has no stored block at height %d to check against yet", height)
+	}
+	parent := s.bc.GetBlockByHash(expected.ParentHash())
+	if parent == nil {
+		return fmt.Errorf("verifier is missing the parent of block %d", expected.NumberU64())
+	}
+
+	statedb, err := state.New(parent.Root(), s.bc.StateCache())
+	if err != nil {
+		return fmt.Errorf("cannot load state at block %d: %w", parent.NumberU64(), err)
+	}
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     expected.Number(),
+		GasLimit:   expected.GasLimit(),
+		Time:       expected.Time(),
+	}
+	gasPool := new(core.GasPool).AddGas(header.GasLimit)
+	usedGas := new(uint64)
+
+	if _, err := core.ApplyTransaction(s.bc.Config(), s.bc, nil, gasPool, statedb, header, tx, usedGas, vm.Config{}); err != nil {
+		return fmt.Errorf("cannot apply transaction while verifying block %d: %w", expected.NumberU64(), err)
+	}
+
+	gotRoot := statedb.IntermediateRoot(s.bc.Config().IsEIP158(header.Number))
+	if gotRoot != expected.Root() {
+		divergence := &RollupDivergence{
+			BlockNumber:  expected.NumberU64(),
+			TxIndex:      0,
+			ExpectedRoot: expected.Root(),
+			GotRoot:      gotRoot,
+			ExpectedHash: expected.Hash(),
+		}
+		log.Error("Rollup divergence detected, halting sync loop", "err", divergence)
+		s.cancel()
+		return divergence
+	}
+	s.nextVerifyHeight++
+	return nil
+}